@@ -0,0 +1,81 @@
+// Package config loads and saves the persisted ankitui session configuration: the
+// last-used deck, ease key remapping, color overrides, AnkiConnect URL, and named query
+// presets offered in the deck picker.
+package config
+
+import (
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// QueryPreset is a named, reusable search query offered alongside decks in the picker.
+type QueryPreset struct {
+	Name  string `yaml:"name"`
+	Query string `yaml:"query"`
+}
+
+// Config is the persisted session configuration for ankitui.
+type Config struct {
+	AnkiConnectURL string            `yaml:"ankiConnectURL,omitempty"`
+	LastDeck       string            `yaml:"lastDeck,omitempty"`
+	LastQuery      string            `yaml:"lastQuery,omitempty"`
+	EaseKeys       map[string]int    `yaml:"easeKeys,omitempty"` // remaps a key press (e.g. "j") to an ease value (1-4)
+	Colors         map[string]string `yaml:"colors,omitempty"`   // style name -> lipgloss hex color override
+	Presets        []QueryPreset     `yaml:"presets,omitempty"`
+}
+
+// Default returns the Config used when no file exists yet.
+func Default() Config {
+	return Config{
+		AnkiConnectURL: "http://localhost:8765",
+	}
+}
+
+// Path returns the config file location: $XDG_CONFIG_HOME/ankitui/config.yaml, falling back
+// to $HOME/.config/ankitui/config.yaml if XDG_CONFIG_HOME isn't set.
+func Path() (string, error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "ankitui", "config.yaml"), nil
+}
+
+// Load reads the config file, returning Default() if it doesn't exist yet or can't be read.
+func Load() Config {
+	path, err := Path()
+	if err != nil {
+		return Default()
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Default()
+	}
+	cfg := Default()
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return Default()
+	}
+	return cfg
+}
+
+// Save writes the config file, creating its directory if needed.
+func Save(cfg Config) error {
+	path, err := Path()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
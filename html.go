@@ -0,0 +1,154 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// mediaFileParams for the retrieveMediaFile action
+type mediaFileParams struct {
+	Filename string `json:"filename"`
+}
+
+var (
+	boldPattern      = regexp.MustCompile(`(?is)<(b|strong)>(.*?)</(b|strong)>`)
+	italicPattern    = regexp.MustCompile(`(?is)<(i|em)>(.*?)</(i|em)>`)
+	underlinePattern = regexp.MustCompile(`(?is)<u>(.*?)</u>`)
+	breakPattern     = regexp.MustCompile(`(?i)<br\s*/?>|<div>|</div>`)
+	imgPattern       = regexp.MustCompile(`(?i)<img[^>]*\bsrc=["']([^"']+)["'][^>]*>`)
+	soundPattern     = regexp.MustCompile(`\[sound:([^\]]+)\]`)
+)
+
+// htmlEntities covers the handful of entities that actually show up in Anki field HTML
+var htmlEntities = strings.NewReplacer(
+	"&nbsp;", " ",
+	"&amp;", "&",
+	"&lt;", "<",
+	"&gt;", ">",
+	"&quot;", "\"",
+	"&#39;", "'",
+)
+
+// htmlToTerminal converts a subset of the HTML found in Anki field values into a terminal
+// rendering: <b>/<strong>, <i>/<em> and <u> become lipgloss styles, <br>/<div> become
+// newlines, <img> tags and [sound:...] refs become bracketed placeholders (with the image
+// cached locally and, on a supporting terminal, displayed inline), and any remaining tags
+// are stripped.
+func htmlToTerminal(s string, st styles) string {
+	s = boldPattern.ReplaceAllStringFunc(s, func(m string) string {
+		groups := boldPattern.FindStringSubmatch(m)
+		return st.htmlBold.Render(groups[2])
+	})
+	s = italicPattern.ReplaceAllStringFunc(s, func(m string) string {
+		groups := italicPattern.FindStringSubmatch(m)
+		return st.htmlItalic.Render(groups[2])
+	})
+	s = underlinePattern.ReplaceAllStringFunc(s, func(m string) string {
+		groups := underlinePattern.FindStringSubmatch(m)
+		return st.htmlUnderline.Render(groups[1])
+	})
+	s = breakPattern.ReplaceAllString(s, "\n")
+	s = imgPattern.ReplaceAllStringFunc(s, func(m string) string {
+		groups := imgPattern.FindStringSubmatch(m)
+		return renderMediaPlaceholder(groups[1])
+	})
+	s = soundPattern.ReplaceAllString(s, "[audio: $1]")
+	s = htmlTagPattern.ReplaceAllString(s, "")
+	s = htmlEntities.Replace(s)
+	return s
+}
+
+// mediaCacheDir returns (creating if needed) the local directory media files are cached in,
+// so the same image isn't re-fetched from AnkiConnect on every card that uses it.
+func mediaCacheDir() (string, error) {
+	dir := filepath.Join(os.TempDir(), "ankitui-media")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// cacheMediaFile fetches a media filename via AnkiConnect's retrieveMediaFile (which returns
+// base64-encoded file contents) and writes it into the local media cache, unless it's already
+// there. It returns the cached file's path.
+func cacheMediaFile(filename string) (string, error) {
+	dir, err := mediaCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	// Hash the filename rather than using it directly as the cache key, since Anki media
+	// filenames can contain characters that aren't safe across filesystems.
+	sum := sha1.Sum([]byte(filename))
+	cachePath := filepath.Join(dir, hex.EncodeToString(sum[:])+filepath.Ext(filename))
+	if _, err := os.Stat(cachePath); err == nil {
+		return cachePath, nil
+	}
+
+	result, err := postAnkiConnect("retrieveMediaFile", 6, mediaFileParams{Filename: filename})
+	if err != nil {
+		return "", err
+	}
+	encoded, ok := result.(string)
+	if !ok || encoded == "" {
+		return "", fmt.Errorf("media file %q not found", filename)
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode media file %q: %w", filename, err)
+	}
+	if err := ioutil.WriteFile(cachePath, data, 0o644); err != nil {
+		return "", err
+	}
+	return cachePath, nil
+}
+
+// renderMediaPlaceholder caches an <img> tag's referenced file locally and returns a
+// placeholder for it: an inline image escape sequence on a terminal that supports one
+// (kitty/iTerm2), otherwise the cached file's path in brackets, or the original filename in
+// brackets if it couldn't be cached.
+func renderMediaPlaceholder(filename string) string {
+	path, err := cacheMediaFile(filename)
+	if err != nil {
+		return fmt.Sprintf("[image: %s]", filename)
+	}
+	if terminalSupportsInlineImages() {
+		if escape, err := inlineImageEscape(path); err == nil {
+			return escape
+		}
+	}
+	return fmt.Sprintf("[image: %s]", path)
+}
+
+// terminalSupportsInlineImages reports whether the current terminal is kitty or iTerm2, the
+// terminals that inlineImageEscape's iTerm2-style escape sequence is sent to.
+func terminalSupportsInlineImages() bool {
+	term := os.Getenv("TERM")
+	if strings.Contains(term, "kitty") {
+		return true
+	}
+	if os.Getenv("TERM_PROGRAM") == "iTerm.app" {
+		return true
+	}
+	return false
+}
+
+// inlineImageEscape reads an image file and wraps it in the iTerm2 inline-image escape
+// sequence (kitty's graphics protocol is a superset of the same base64-payload idea, but
+// iTerm2's is simpler to emit correctly without a multi-chunk transfer, so we standardize on
+// it here and let kitty's iTerm2-compatibility mode handle the rest).
+func inlineImageEscape(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.StdEncoding.EncodeToString(data)
+	return fmt.Sprintf("\x1b]1337;File=inline=1;size=%d:%s\a", len(data), encoded), nil
+}
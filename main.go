@@ -3,25 +3,65 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
-	"strconv" // For converting string to int for ease options
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	lipgloss "github.com/charmbracelet/lipgloss"
+
+	"github.com/Jakelamers64/ankitui/config"
 )
 
-// AnkiConnect API endpoint
-const ankiConnectURL = "http://localhost:8765"
+// ankiConnectURL is the AnkiConnect API endpoint; overridable via the config file's
+// ankiConnectURL key, hence a var rather than a const.
+var ankiConnectURL = "http://localhost:8765"
 
 // Card represents a simplified Anki card for our TUI
 type Card struct {
-	ID    int64
-	Front string
-	Back  string
-	EaseOptions map[int]string // Map ease value (1-4) to button text (e.g., "Again", "Good")
+	ID                 int64
+	Front              string         // Rendered question side, converted to terminal output
+	Back               string         // Rendered answer side, converted to terminal output
+	BackPlainText      string         // Back field (or answer side, if the note type has no Back field) with markup stripped, used for typed-answer comparison
+	EaseOptions        map[int]string // Map ease value (1-4) to button text (e.g., "Again", "Good")
+	TypedAnswerEnabled bool           // Whether this card's note type supports typed-answer study
+
+	Phase           cardPhase // Where this card is in the Question -> Review -> Answer lifecycle
+	ReviewResult    int       // Ease value (1-4) the user graded this card with, once answered
+	QuestionShownAt time.Time // When this card became the current card
+	AnsweredAt      time.Time // When the user submitted a grade for this card
+}
+
+// cardPhase is the stage of a single card's Question -> Review -> Answer lifecycle
+type cardPhase int
+
+const (
+	phaseQuestion cardPhase = iota // front shown, waiting for the user to recall the answer
+	phaseReview                    // the active ReviewMode handles recalling/typing, revealing and grading
+	phaseAnswer                    // graded; waiting for AnkiConnect to record the answer
+)
+
+// diffKind classifies a run of runes in a typed-answer diff
+type diffKind int
+
+const (
+	diffMatch   diffKind = iota // rune run present in both the typed answer and the back field
+	diffMissing                 // rune run present on the back field but not typed
+	diffExtra                   // rune run typed but not present on the back field
+)
+
+// DiffSegment is one colorized run in a typed-answer diff
+type DiffSegment struct {
+	Text string
+	Kind diffKind
 }
 
 // AnkiConnectRequest is a generic structure for AnkiConnect API calls
@@ -49,16 +89,29 @@ type cardsInfoParams struct {
 
 // cardInfoResult represents a single card's info from cardsInfo response
 type cardInfoResult struct {
-	CardID int64 `json:"cardId"`
-	Fields struct {
-		Front struct {
-			Value string `json:"value"`
-		} `json:"Front"`
-		Back struct {
-			Value string `json:"value"`
-		} `json:"Back"`
-	} `json:"fields"`
-	Buttons []int `json:"buttons"` // Array of ease values (1, 2, 3, 4)
+	CardID    int64                 `json:"cardId"`
+	ModelName string                `json:"modelName"`
+	DeckName  string                `json:"deckName"`
+	Ord       int                   `json:"ord"` // Which of the note type's card templates this is
+	Fields    map[string]fieldValue `json:"fields"`
+	Buttons   []int                 `json:"buttons"` // Array of ease values (1, 2, 3, 4)
+}
+
+// fieldValue mirrors one entry of AnkiConnect's cardsInfo "fields" map: every note-type
+// field, not just a hardcoded Front/Back pair.
+type fieldValue struct {
+	Value string `json:"value"`
+	Order int    `json:"order"`
+}
+
+// modelFieldNamesParams for the modelFieldNames action
+type modelFieldNamesParams struct {
+	ModelName string `json:"modelName"`
+}
+
+// deckConfigParams for the getDeckConfig action
+type deckConfigParams struct {
+	Deck string `json:"deck"`
 }
 
 // answerCardsParams for the answerCards action
@@ -69,164 +122,305 @@ type answerCardsParams struct {
 
 // Msg types for async operations
 type (
-	cardsLoadedMsg []Card // Sent when cards are successfully loaded
-	errMsg         error  // Sent when an error occurs
+	// cardsLoadedMsg is sent when cards are successfully loaded. reviewModeOverride is the
+	// value of a "reviewMode" key found in the first card's deck config, if any, and takes
+	// precedence over the CLI flag for this session.
+	cardsLoadedMsg struct {
+		cards              []Card
+		reviewModeOverride string
+	}
+	errMsg          error // Sent when an error occurs
 	cardAnsweredMsg struct {
 		cardID int64
 		ease   int
 	} // Sent when a card is successfully answered
+
+	// decksLoadedMsg is sent once deckNames has returned, ready to populate the deck picker.
+	decksLoadedMsg struct {
+		decks []string
+	}
 )
 
 // model represents the state of our TUI application
 type model struct {
 	cards            []Card
 	currentCardIndex int
-	showBack         bool
 	state            appState
 	err              error
 	ankiConnectURL   string
 	quitting         bool
 	styles           styles
+	stats            sessionStats
+	reviewMode       ReviewMode // how the current session recalls and grades cards
+	reviewModeFlag   string     // review mode requested on the command line, used unless a deck overrides it
+
+	cfg                 config.Config
+	query               string // the AnkiConnect search query the current/last session was loaded with
+	deckList            list.Model
+	customQueryInput    textinput.Model
+	choosingCustomQuery bool // true once "Custom query..." has been picked, until it's submitted
+}
+
+// sessionStats accumulates across a study session for the end-of-session summary
+type sessionStats struct {
+	reviewed     int
+	easeCounts   map[int]int
+	totalLatency time.Duration // sum of (AnsweredAt - QuestionShownAt) across reviewed cards
+}
+
+// newSessionStats returns a zeroed sessionStats ready to accumulate a new session
+func newSessionStats() sessionStats {
+	return sessionStats{easeCounts: make(map[int]int)}
 }
 
 // appState defines the different states of the application
 type appState int
 
 const (
-	stateLoading appState = iota
+	stateDeckPicker appState = iota // choosing a deck, preset, or custom query to study
+	stateLoading
 	stateDisplayingCard
+	stateSessionDone // every loaded card has been reviewed; showing the summary panel
 	stateNoCards
 	stateError
 	stateQuitting
 )
 
+// customQueryItemTitle is the deck picker's always-present entry for typing an arbitrary
+// AnkiConnect search query instead of picking a deck or preset.
+const customQueryItemTitle = "Custom query..."
+
+// deckPickerItem is one entry in the deck picker's bubbles/list: a deck, a named query
+// preset, or the custom-query entry (identified by its customQueryItemTitle name).
+type deckPickerItem struct {
+	name  string
+	query string
+}
+
+func (i deckPickerItem) Title() string { return i.name }
+func (i deckPickerItem) Description() string {
+	if i.name == customQueryItemTitle {
+		return "Type your own AnkiConnect search query"
+	}
+	return i.query
+}
+func (i deckPickerItem) FilterValue() string { return i.name }
+
+// isCustomQuery reports whether this item is the "type your own query" entry.
+func (i deckPickerItem) isCustomQuery() bool { return i.name == customQueryItemTitle }
+
 // styles for lipgloss
 type styles struct {
-	title  lipgloss.Style
-	status lipgloss.Style
-	card   lipgloss.Style
-	front  lipgloss.Style
-	back   lipgloss.Style
-	prompt lipgloss.Style
-	error  lipgloss.Style
-	button lipgloss.Style
-}
-
-// newStyles initializes and returns the lipgloss styles
-func newStyles() styles {
+	title         lipgloss.Style
+	status        lipgloss.Style
+	card          lipgloss.Style
+	front         lipgloss.Style
+	back          lipgloss.Style
+	prompt        lipgloss.Style
+	error         lipgloss.Style
+	button        lipgloss.Style
+	diffMatch     lipgloss.Style
+	diffMissing   lipgloss.Style
+	diffExtra     lipgloss.Style
+	htmlBold      lipgloss.Style
+	htmlItalic    lipgloss.Style
+	htmlUnderline lipgloss.Style
+}
+
+// styleColor returns cfg.Colors[name] as a lipgloss.Color if set, otherwise fallback, so a
+// user's color overrides apply without every style losing its default.
+func styleColor(cfg config.Config, name, fallback string) lipgloss.Color {
+	if hex, ok := cfg.Colors[name]; ok && hex != "" {
+		return lipgloss.Color(hex)
+	}
+	return lipgloss.Color(fallback)
+}
+
+// newStyles initializes and returns the lipgloss styles, applying any color overrides from
+// the loaded config.
+func newStyles(cfg config.Config) styles {
 	return styles{
 		title: lipgloss.NewStyle().
 			Bold(true).
-			Foreground(lipgloss.Color("#7D56F4")).
+			Foreground(styleColor(cfg, "title", "#7D56F4")).
 			Padding(0, 1),
 		status: lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#888888")).
+			Foreground(styleColor(cfg, "status", "#888888")).
 			Padding(0, 1),
 		card: lipgloss.NewStyle().
 			Border(lipgloss.RoundedBorder(), true).
-			BorderForeground(lipgloss.Color("#6243A6")).
+			BorderForeground(styleColor(cfg, "card", "#6243A6")).
 			Padding(1, 2).
 			Width(60).
 			Align(lipgloss.Center),
 		front: lipgloss.NewStyle().
 			Bold(true).
-			Foreground(lipgloss.Color("#FFFFFF")).
+			Foreground(styleColor(cfg, "front", "#FFFFFF")).
 			Padding(0, 1),
 		back: lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#DDDDDD")).
+			Foreground(styleColor(cfg, "back", "#DDDDDD")).
 			Padding(0, 1),
 		prompt: lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#AAAAAA")).
+			Foreground(styleColor(cfg, "prompt", "#AAAAAA")).
 			PaddingTop(1),
 		error: lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#FF0000")).
+			Foreground(styleColor(cfg, "error", "#FF0000")).
 			Bold(true).
 			Padding(0, 1),
 		button: lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#FFFFFF")).
-			Background(lipgloss.Color("#5A56E0")).
+			Foreground(styleColor(cfg, "buttonText", "#FFFFFF")).
+			Background(styleColor(cfg, "button", "#5A56E0")).
 			Padding(0, 1).
 			MarginRight(1),
+		diffMatch: lipgloss.NewStyle().
+			Foreground(styleColor(cfg, "diffMatch", "#00D700")),
+		diffMissing: lipgloss.NewStyle().
+			Foreground(styleColor(cfg, "diffMissing", "#FF0000")),
+		diffExtra: lipgloss.NewStyle().
+			Foreground(styleColor(cfg, "diffExtra", "#888888")).
+			Strikethrough(true),
+		htmlBold: lipgloss.NewStyle().
+			Bold(true),
+		htmlItalic: lipgloss.NewStyle().
+			Italic(true),
+		htmlUnderline: lipgloss.NewStyle().
+			Underline(true),
 	}
 }
 
 // InitialModel returns the initial state of the model
-func InitialModel() model {
+func InitialModel(reviewModeFlag string, cfg config.Config) model {
+	deckList := list.New(nil, list.NewDefaultDelegate(), 0, 0)
+	deckList.Title = "Choose a deck to study"
+
+	customQueryInput := newTypedInput()
+	customQueryInput.Placeholder = "is:due deck:..."
+
 	return model{
-		state:          stateLoading,
-		ankiConnectURL: ankiConnectURL,
-		styles:         newStyles(),
+		state:            stateDeckPicker,
+		ankiConnectURL:   ankiConnectURL,
+		styles:           newStyles(cfg),
+		stats:            newSessionStats(),
+		reviewMode:       newReviewMode(reviewModeFlag),
+		reviewModeFlag:   reviewModeFlag,
+		cfg:              cfg,
+		deckList:         deckList,
+		customQueryInput: customQueryInput,
 	}
 }
 
+// newTypedInput builds a fresh textinput.Model for the Review phase
+func newTypedInput() textinput.Model {
+	ti := textinput.New()
+	ti.Placeholder = "Type the answer..."
+	ti.Focus()
+	ti.CharLimit = 256
+	ti.Width = 50
+	return ti
+}
+
 // Init is called once when the program starts. It performs initial setup.
 func (m model) Init() tea.Cmd {
-	return m.fetchDueCardsCmd() // Start fetching cards immediately
+	return m.fetchDeckNamesCmd() // Populate the deck picker before fetching any cards
 }
 
 // Update handles messages and updates the model's state
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		if msg.String() == "ctrl+c" {
+			m.quitting = true
+			return m, tea.Quit
+		}
+
+		if m.state == stateDeckPicker {
+			return m.updateDeckPicker(msg)
+		}
+
+		if m.state == stateDisplayingCard && m.currentCardIndex < len(m.cards) {
+			return m.updateDisplayingCard(msg)
+		}
+
 		switch msg.String() {
-		case "q", "ctrl+c":
+		case "q":
 			m.quitting = true
 			return m, tea.Quit
 
-		case "enter":
-			if m.state == stateDisplayingCard {
-				if m.showBack {
-					// If back is shown, pressing enter moves to next card (if no ease option selected)
-					// Or, if ease options are displayed, it does nothing until an ease button is pressed.
-					return m, nil
-				}
-				m.showBack = true // Flip card to show back
+		case "r": // Re-query AnkiConnect and start a new session without restarting the binary
+			if m.state == stateSessionDone || m.state == stateNoCards {
+				m.state = stateLoading
+				return m, m.fetchDueCardsCmd(m.query)
 			}
 
-		case "1", "2", "3", "4":
-			if m.state == stateDisplayingCard && m.showBack {
-				ease, err := strconv.Atoi(msg.String())
-				if err != nil {
-          m.err = fmt.Errorf("invalid ease input: %w", err) // Set the error directly on the model
-					m.state = stateError                               // Change the app state to error
-					return m, nil  
-        }
-				if m.currentCardIndex < len(m.cards) {
-					card := m.cards[m.currentCardIndex]
-					// Check if the selected ease value is valid for the current card
-					if _, ok := card.EaseOptions[ease]; ok {
-						return m, m.answerCardCmd(card.ID, ease)
-					}
-				}
+		case "d": // Back to the deck picker to choose a different deck, preset, or query
+			if m.state == stateSessionDone || m.state == stateNoCards {
+				m.state = stateDeckPicker
+				return m, m.fetchDeckNamesCmd()
 			}
+		}
 
-		case "right", "n": // For debugging or skipping cards without answering (not recommended for actual study)
-			if m.state == stateDisplayingCard {
-				m.currentCardIndex++
-				m.showBack = false
-				if m.currentCardIndex >= len(m.cards) {
-					m.state = stateNoCards // All cards studied/skipped
-				}
+	case tea.WindowSizeMsg:
+		m.deckList.SetSize(msg.Width, msg.Height)
+		return m, nil
+
+	case decksLoadedMsg:
+		items := make([]list.Item, 0, len(msg.decks)+len(m.cfg.Presets)+1)
+		items = append(items, deckPickerItem{name: customQueryItemTitle})
+		for _, preset := range m.cfg.Presets {
+			items = append(items, deckPickerItem{name: preset.Name, query: preset.Query})
+		}
+		for _, deck := range msg.decks {
+			items = append(items, deckPickerItem{name: deck, query: fmt.Sprintf("deck:%q is:due", deck)})
+		}
+		m.deckList.SetItems(items)
+		m.customQueryInput.SetValue(m.cfg.LastQuery)
+
+		matchedLastDeck := false
+		for i, item := range items {
+			if di, ok := item.(deckPickerItem); ok && di.name == m.cfg.LastDeck {
+				m.deckList.Select(i)
+				matchedLastDeck = true
+				break
 			}
 		}
+		if !matchedLastDeck && m.cfg.LastQuery != "" {
+			// The last session was a custom query (no deck to match), so resume by
+			// pre-selecting "Custom query..." with its text already filled in.
+			m.deckList.Select(0)
+		}
+		return m, nil
 
 	case cardsLoadedMsg:
-		if len(msg) == 0 {
+		m.stats = newSessionStats()
+		if msg.reviewModeOverride != "" {
+			m.reviewMode = newReviewMode(msg.reviewModeOverride)
+		} else {
+			m.reviewMode = newReviewMode(m.reviewModeFlag)
+		}
+		if len(msg.cards) == 0 {
 			m.state = stateNoCards
 		} else {
-			m.cards = msg
+			m.cards = msg.cards
 			m.state = stateDisplayingCard
 			m.currentCardIndex = 0
-			m.showBack = false
+			m.cards[0].Phase = phaseQuestion
+			m.cards[0].QuestionShownAt = time.Now()
 		}
 		return m, nil
 
 	case cardAnsweredMsg:
-		// Move to the next card after answering
+		if m.currentCardIndex < len(m.cards) {
+			card := m.cards[m.currentCardIndex]
+			m.stats.reviewed++
+			m.stats.easeCounts[card.ReviewResult]++
+			m.stats.totalLatency += card.AnsweredAt.Sub(card.QuestionShownAt)
+		}
 		m.currentCardIndex++
-		m.showBack = false
 		if m.currentCardIndex >= len(m.cards) {
-			m.state = stateNoCards // All cards studied
+			m.state = stateSessionDone // All cards studied
+		} else {
+			m.cards[m.currentCardIndex].Phase = phaseQuestion
+			m.cards[m.currentCardIndex].QuestionShownAt = time.Now()
 		}
 		return m, nil
 
@@ -239,6 +433,106 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// updateDeckPicker handles input while choosing a deck, preset, or custom query: either
+// navigating/filtering the bubbles/list of decks, or typing a query once "Custom query..."
+// has been selected.
+func (m model) updateDeckPicker(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.choosingCustomQuery {
+		if msg.String() == "enter" {
+			return m.startSession(m.customQueryInput.Value(), "")
+		}
+		var cmd tea.Cmd
+		m.customQueryInput, cmd = m.customQueryInput.Update(msg)
+		return m, cmd
+	}
+
+	// While the list is in its own Filtering mode, every keystroke - including "q" and
+	// "enter" - belongs to its filter text box/accept-filter binding, not to us.
+	filtering := m.deckList.FilterState() == list.Filtering
+
+	if !filtering && msg.String() == "q" {
+		m.quitting = true
+		return m, tea.Quit
+	}
+
+	if !filtering && msg.String() == "enter" {
+		selected, ok := m.deckList.SelectedItem().(deckPickerItem)
+		if !ok {
+			return m, nil
+		}
+		if selected.isCustomQuery() {
+			// Keep customQueryInput's current value (pre-filled from cfg.LastQuery, the last
+			// session's query) rather than resetting it, so re-entering this entry
+			// doesn't lose what was already typed.
+			m.choosingCustomQuery = true
+			m.customQueryInput.Focus()
+			return m, nil
+		}
+		return m.startSession(selected.query, selected.name)
+	}
+
+	var cmd tea.Cmd
+	m.deckList, cmd = m.deckList.Update(msg)
+	return m, cmd
+}
+
+// startSession persists the chosen deck/query as the config's last-used session and begins
+// loading its due cards. deckName is "" for a custom typed query, which has no deck to save.
+func (m model) startSession(query, deckName string) (tea.Model, tea.Cmd) {
+	m.query = query
+	m.cfg.LastDeck = deckName
+	m.cfg.LastQuery = query
+	if err := config.Save(m.cfg); err != nil {
+		log.Printf("Warning: failed to save config: %v", err)
+	}
+	m.state = stateLoading
+	return m, m.fetchDueCardsCmd(query)
+}
+
+// updateDisplayingCard dispatches a key press based on the current card's phase in the
+// Question -> Review -> Answer lifecycle. In the Review phase all interaction is delegated
+// to the active ReviewMode so this stays generic across modes; in the Answer phase key
+// presses are ignored until the answer has been recorded.
+func (m model) updateDisplayingCard(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	card := &m.cards[m.currentCardIndex]
+
+	switch card.Phase {
+	case phaseQuestion:
+		switch msg.String() {
+		case "q":
+			m.quitting = true
+			return m, tea.Quit
+		case "enter":
+			card.Phase = phaseReview
+			m.reviewMode.Reset(*card, m.cards)
+		case "right", "n": // For debugging or skipping cards without answering (not recommended for actual study)
+			card.AnsweredAt = time.Now()
+			return m.Update(cardAnsweredMsg{cardID: card.ID, ease: 0})
+		}
+		return m, nil
+
+	case phaseReview:
+		// A configured ease key remap (e.g. "j"/"k" instead of digits) is rewritten to the
+		// digit keystroke the ReviewMode implementations already expect, so remapping doesn't
+		// need to touch every mode. This does mean a remap key can't double as typed-answer
+		// input in auto/typed review modes; that's an accepted tradeoff of keeping HandleKey's
+		// per-mode logic untouched.
+		if ease, ok := m.cfg.EaseKeys[msg.String()]; ok {
+			msg = tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(strconv.Itoa(ease))}
+		}
+		ease, done, cmd := m.reviewMode.HandleKey(msg, *card)
+		if done {
+			card.ReviewResult = ease
+			card.Phase = phaseAnswer
+			card.AnsweredAt = time.Now()
+			return m, m.answerCardCmd(card.ID, ease)
+		}
+		return m, cmd
+	}
+
+	return m, nil
+}
+
 // View renders the TUI
 func (m model) View() string {
 	if m.quitting {
@@ -250,6 +544,12 @@ func (m model) View() string {
 	status := ""
 
 	switch m.state {
+	case stateDeckPicker:
+		if m.choosingCustomQuery {
+			s = m.customQueryInput.View() + "\n\n" + m.styles.prompt.Render("Press ENTER to search")
+		} else {
+			s = m.deckList.View()
+		}
 	case stateLoading:
 		s = "Loading cards from AnkiConnect...\n"
 	case stateDisplayingCard:
@@ -257,20 +557,20 @@ func (m model) View() string {
 			card := m.cards[m.currentCardIndex]
 			status = m.styles.status.Render(fmt.Sprintf("Card %d/%d", m.currentCardIndex+1, len(m.cards)))
 			cardContent := m.styles.front.Render(card.Front)
-			if m.showBack {
-				cardContent += "\n\n" + m.styles.back.Render(card.Back)
-				cardContent += "\n\n" + m.styles.prompt.Render("Press 1-4 to answer:")
-				for easeVal, easeText := range card.EaseOptions {
-					cardContent += fmt.Sprintf(" %s", m.styles.button.Render(fmt.Sprintf("%d: %s", easeVal, easeText)))
-				}
-			} else {
-				cardContent += "\n\n" + m.styles.prompt.Render("Press ENTER to reveal back")
+
+			switch card.Phase {
+			case phaseQuestion:
+				cardContent += "\n\n" + m.styles.prompt.Render("Press ENTER to begin")
+			case phaseReview, phaseAnswer:
+				cardContent += "\n\n" + m.reviewMode.Render(card, m)
 			}
 			s = m.styles.card.Render(cardContent)
 		}
+	case stateSessionDone:
+		s = m.renderSessionSummary()
 	case stateNoCards:
 		s = "No cards due today! Great job!\n"
-		s += m.styles.prompt.Render("Press 'q' to quit.")
+		s += m.styles.prompt.Render("Press 'r' to check again, 'd' to pick a different deck, or 'q' to quit.")
 	case stateError:
 		s = m.styles.error.Render(fmt.Sprintf("Error: %v\n", m.err))
 		s += m.styles.prompt.Render("Press 'q' to quit.")
@@ -279,6 +579,120 @@ func (m model) View() string {
 	return lipgloss.JoinVertical(lipgloss.Center, header, status, s)
 }
 
+// renderSessionSummary renders the end-of-session panel: totals, per-ease breakdown, and
+// average answer latency, once every loaded card has been reviewed.
+func (m model) renderSessionSummary() string {
+	s := m.styles.prompt.Render(fmt.Sprintf("Session complete! Reviewed %d card(s).", m.stats.reviewed))
+
+	easeLabels := []struct {
+		ease  int
+		label string
+	}{
+		{1, "Again"},
+		{2, "Hard"},
+		{3, "Good"},
+		{4, "Easy"},
+	}
+	for _, el := range easeLabels {
+		s += "\n" + m.styles.back.Render(fmt.Sprintf("%s: %d", el.label, m.stats.easeCounts[el.ease]))
+	}
+
+	if m.stats.reviewed > 0 {
+		avgLatency := m.stats.totalLatency / time.Duration(m.stats.reviewed)
+		s += "\n\n" + m.styles.back.Render(fmt.Sprintf("Average answer time: %s", avgLatency.Round(time.Millisecond)))
+	}
+
+	s += "\n\n" + m.styles.prompt.Render("Press 'r' to repeat this session, 'd' to pick a different deck, or 'q' to quit.")
+	return m.styles.card.Render(s)
+}
+
+// renderDiff renders a card's typed-answer diff segments with the configured styles
+func (m model) renderDiff(segments []DiffSegment) string {
+	rendered := ""
+	for _, seg := range segments {
+		switch seg.Kind {
+		case diffMatch:
+			rendered += m.styles.diffMatch.Render(seg.Text)
+		case diffMissing:
+			rendered += m.styles.diffMissing.Render(seg.Text)
+		case diffExtra:
+			rendered += m.styles.diffExtra.Render(seg.Text)
+		}
+	}
+	return rendered
+}
+
+// htmlTagPattern strips simple HTML tags out of an Anki field so typed input can be compared fairly
+var htmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// normalizeAnswerText strips HTML tags and collapses whitespace from an Anki field value
+func normalizeAnswerText(s string) string {
+	s = htmlTagPattern.ReplaceAllString(s, "")
+	s = strings.Join(strings.Fields(s), " ")
+	return strings.TrimSpace(s)
+}
+
+// computeDiffSegments compares a typed answer against the card's back field using a
+// rune-level longest-common-subsequence diff, so partially correct answers still highlight
+// the runs that matched instead of marking the whole answer wrong.
+func computeDiffSegments(typed, expected string) []DiffSegment {
+	typed = normalizeAnswerText(typed)
+	expected = normalizeAnswerText(expected)
+
+	a := []rune(typed)
+	b := []rune(expected)
+
+	// lcsTable[i][j] = length of the LCS of a[i:] and b[j:]
+	lcsTable := make([][]int, len(a)+1)
+	for i := range lcsTable {
+		lcsTable[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcsTable[i][j] = lcsTable[i+1][j+1] + 1
+			} else if lcsTable[i+1][j] >= lcsTable[i][j+1] {
+				lcsTable[i][j] = lcsTable[i+1][j]
+			} else {
+				lcsTable[i][j] = lcsTable[i][j+1]
+			}
+		}
+	}
+
+	var segments []DiffSegment
+	appendRune := func(kind diffKind, r rune) {
+		if n := len(segments); n > 0 && segments[n-1].Kind == kind {
+			segments[n-1].Text += string(r)
+			return
+		}
+		segments = append(segments, DiffSegment{Kind: kind, Text: string(r)})
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			appendRune(diffMatch, a[i])
+			i++
+			j++
+		case lcsTable[i+1][j] >= lcsTable[i][j+1]:
+			appendRune(diffExtra, a[i])
+			i++
+		default:
+			appendRune(diffMissing, b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		appendRune(diffExtra, a[i])
+	}
+	for ; j < len(b); j++ {
+		appendRune(diffMissing, b[j])
+	}
+
+	return segments
+}
+
 // postAnkiConnect sends a request to the AnkiConnect API
 func postAnkiConnect(action string, version int, params interface{}) (interface{}, error) {
 	reqBody, err := json.Marshal(AnkiConnectRequest{
@@ -313,11 +727,75 @@ func postAnkiConnect(action string, version int, params interface{}) (interface{
 	return ankiResp.Result, nil
 }
 
-// fetchDueCardsCmd is a tea.Cmd that fetches due cards asynchronously
-func (m model) fetchDueCardsCmd() tea.Cmd {
+// modelSupportsTypedAnswer looks up a note type's field names via AnkiConnect and reports
+// whether it looks like a Basic-style model (has both a "Front" and a "Back" field), which
+// we treat as eligible for typed-answer study since there's a clear expected-answer field.
+func modelSupportsTypedAnswer(modelName string) bool {
+	result, err := postAnkiConnect("modelFieldNames", 6, modelFieldNamesParams{ModelName: modelName})
+	if err != nil {
+		return false
+	}
+	fieldNames, ok := result.([]interface{})
+	if !ok {
+		return false
+	}
+	hasFront, hasBack := false, false
+	for _, f := range fieldNames {
+		switch f {
+		case "Front":
+			hasFront = true
+		case "Back":
+			hasBack = true
+		}
+	}
+	return hasFront && hasBack
+}
+
+// deckReviewModeOverride looks for a "reviewMode" key in a deck's AnkiConnect config (a
+// field a user can add via the Anki deck options screen's "extra JSON" or saveDeckConfig)
+// and returns its value, or "" if absent so the caller falls back to the CLI flag.
+func deckReviewModeOverride(deckName string) string {
+	if deckName == "" {
+		return ""
+	}
+	result, err := postAnkiConnect("getDeckConfig", 6, deckConfigParams{Deck: deckName})
+	if err != nil {
+		return ""
+	}
+	deckConfig, ok := result.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	mode, _ := deckConfig["reviewMode"].(string)
+	return mode
+}
+
+// fetchDeckNamesCmd is a tea.Cmd that fetches the deck picker's deck list asynchronously
+func (m model) fetchDeckNamesCmd() tea.Cmd {
+	return func() tea.Msg {
+		result, err := postAnkiConnect("deckNames", 6, nil)
+		if err != nil {
+			return errMsg(fmt.Errorf("failed to fetch deck names: %w", err))
+		}
+		raw, ok := result.([]interface{})
+		if !ok {
+			return errMsg(fmt.Errorf("unexpected deckNames result format: %T", result))
+		}
+		decks := make([]string, 0, len(raw))
+		for _, d := range raw {
+			if name, ok := d.(string); ok {
+				decks = append(decks, name)
+			}
+		}
+		return decksLoadedMsg{decks: decks}
+	}
+}
+
+// fetchDueCardsCmd is a tea.Cmd that fetches due cards matching query asynchronously
+func (m model) fetchDueCardsCmd(query string) tea.Cmd {
 	return func() tea.Msg {
 		// 1. Find due card IDs
-		findCardsResult, err := postAnkiConnect("findCards", 6, findCardsParams{Query: "is:due"})
+		findCardsResult, err := postAnkiConnect("findCards", 6, findCardsParams{Query: query})
 		if err != nil {
 			return errMsg(fmt.Errorf("failed to find due cards: %w", err))
 		}
@@ -344,6 +822,8 @@ func (m model) fetchDueCardsCmd() tea.Cmd {
 		}
 
 		ankiCards := []Card{}
+		typedAnswerModels := map[string]bool{} // cache of modelName -> eligible for typed-answer mode
+		firstDeckName := ""
 		if infos, ok := cardsInfoResult.([]interface{}); ok {
 			for _, info := range infos {
 				infoBytes, err := json.Marshal(info) // Marshal back to bytes to unmarshal into specific struct
@@ -379,18 +859,32 @@ func (m model) fetchDueCardsCmd() tea.Cmd {
 					}
 				}
 
+				typedEnabled, ok := typedAnswerModels[ci.ModelName]
+				if !ok {
+					typedEnabled = modelSupportsTypedAnswer(ci.ModelName)
+					typedAnswerModels[ci.ModelName] = typedEnabled
+				}
+
+				if firstDeckName == "" {
+					firstDeckName = ci.DeckName
+				}
+
+				front, back, backPlain := renderCard(ci, m.styles)
+
 				ankiCards = append(ankiCards, Card{
-					ID:    ci.CardID,
-					Front: ci.Fields.Front.Value,
-					Back:  ci.Fields.Back.Value,
-					EaseOptions: easeOptions,
+					ID:                 ci.CardID,
+					Front:              front,
+					Back:               back,
+					BackPlainText:      backPlain,
+					EaseOptions:        easeOptions,
+					TypedAnswerEnabled: typedEnabled,
 				})
 			}
 		} else {
 			return errMsg(fmt.Errorf("unexpected cardsInfo result format: %T", cardsInfoResult))
 		}
 
-		return cardsLoadedMsg(ankiCards)
+		return cardsLoadedMsg{cards: ankiCards, reviewModeOverride: deckReviewModeOverride(firstDeckName)}
 	}
 }
 
@@ -409,10 +903,17 @@ func (m model) answerCardCmd(cardID int64, ease int) tea.Cmd {
 }
 
 func main() {
-	p := tea.NewProgram(InitialModel())
+	reviewMode := flag.String("mode", "self", "review mode: self (flip & self-grade), auto (typed & auto-graded), or choice (multiple choice)")
+	flag.Parse()
+
+	cfg := config.Load()
+	if cfg.AnkiConnectURL != "" {
+		ankiConnectURL = cfg.AnkiConnectURL
+	}
+
+	p := tea.NewProgram(InitialModel(*reviewMode, cfg))
 
 	if _, err := p.Run(); err != nil {
 		log.Fatalf("Alas, there's been an error: %v", err)
 	}
 }
-
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ReviewMode controls how a card is recalled and graded once the user moves past the
+// Question phase: flipping the card and self-grading, typing the answer for auto-grading,
+// or picking from a set of choices. Keeping this behind an interface lets Update/View stay
+// generic over the three-phase card lifecycle instead of hardcoding one review flow.
+type ReviewMode interface {
+	// Reset prepares the mode's internal state for a newly-shown card. allCards is the full
+	// session, for modes (like MultipleChoice) that need to sample other cards' fields.
+	Reset(card Card, allCards []Card)
+	// Render returns the content to show below the card's front once Reset has been called.
+	Render(card Card, m model) string
+	// HandleKey processes a key press while this mode is active. If done is true the card
+	// has been graded with the returned ease and should be submitted via answerCardCmd; cmd
+	// is forwarded to the Bubble Tea runtime (e.g. a textinput update) and may be nil.
+	HandleKey(msg tea.KeyMsg, card Card) (ease int, done bool, cmd tea.Cmd)
+}
+
+// reviewModeNames maps the CLI flag / deck-config values to their constructors
+var reviewModeNames = map[string]func() ReviewMode{
+	"self":   func() ReviewMode { return &ImmediateSelfReview{} },
+	"auto":   func() ReviewMode { return &AutoReview{} },
+	"choice": func() ReviewMode { return &MultipleChoice{} },
+}
+
+// newReviewMode builds a ReviewMode by name, falling back to ImmediateSelfReview for an
+// unrecognized name so a typo'd flag or deck override never wedges the app.
+func newReviewMode(name string) ReviewMode {
+	if ctor, ok := reviewModeNames[name]; ok {
+		return ctor()
+	}
+	return &ImmediateSelfReview{}
+}
+
+// ImmediateSelfReview is the default review mode: flip the card (optionally via a typed
+// answer check first, for note types that support it) and self-grade with ease 1-4.
+type ImmediateSelfReview struct {
+	typing   bool // still typing an answer (TypedAnswerEnabled cards only)
+	revealed bool
+	input    textinput.Model
+	diff     []DiffSegment
+}
+
+func (r *ImmediateSelfReview) Reset(card Card, allCards []Card) {
+	r.typing = card.TypedAnswerEnabled
+	r.revealed = false
+	r.input = newTypedInput()
+	r.diff = nil
+}
+
+func (r *ImmediateSelfReview) Render(card Card, m model) string {
+	if r.typing {
+		return r.input.View() + "\n\n" + m.styles.prompt.Render("Press ENTER to check your answer")
+	}
+	if !r.revealed {
+		return m.styles.prompt.Render("Press ENTER to reveal back")
+	}
+
+	content := ""
+	if r.diff != nil {
+		content = m.renderDiff(r.diff)
+	} else {
+		content = m.styles.back.Render(card.Back)
+	}
+	content += "\n\n" + m.styles.prompt.Render("Press 1-4 to answer:")
+	for easeVal, easeText := range card.EaseOptions {
+		content += fmt.Sprintf(" %s", m.styles.button.Render(fmt.Sprintf("%d: %s", easeVal, easeText)))
+	}
+	return content
+}
+
+func (r *ImmediateSelfReview) HandleKey(msg tea.KeyMsg, card Card) (int, bool, tea.Cmd) {
+	if r.typing {
+		if msg.String() == "enter" {
+			r.diff = computeDiffSegments(r.input.Value(), card.BackPlainText)
+			r.typing = false
+			r.revealed = true
+			return 0, false, nil
+		}
+		var cmd tea.Cmd
+		r.input, cmd = r.input.Update(msg)
+		return 0, false, cmd
+	}
+
+	if !r.revealed {
+		if msg.String() == "enter" {
+			r.revealed = true
+		}
+		return 0, false, nil
+	}
+
+	if ease, err := strconv.Atoi(msg.String()); err == nil {
+		if _, ok := card.EaseOptions[ease]; ok {
+			return ease, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// nearestAvailableEase returns ease if the card actually offers it (via card.EaseOptions,
+// populated from AnkiConnect's per-card "buttons"), otherwise the nearest lower ease the card
+// offers, or failing that the nearest higher one. Cards in early learning steps often only expose
+// eases 1-3 (no "Easy"), so a mode that derives a fixed ease like AutoReview or MultipleChoice
+// must not hand AnkiConnect a button the card isn't currently showing.
+func nearestAvailableEase(card Card, ease int) int {
+	if _, ok := card.EaseOptions[ease]; ok {
+		return ease
+	}
+	for e := ease - 1; e >= 1; e-- {
+		if _, ok := card.EaseOptions[e]; ok {
+			return e
+		}
+	}
+	for e := ease + 1; e <= 4; e++ {
+		if _, ok := card.EaseOptions[e]; ok {
+			return e
+		}
+	}
+	return ease
+}
+
+// AutoReview has the user type the back field and auto-grades the result: a match (after
+// HTML/whitespace normalization) grades ease 3 (Good), anything else grades ease 1 (Again).
+type AutoReview struct {
+	input     textinput.Model
+	submitted bool
+	diff      []DiffSegment
+	ease      int
+}
+
+func (a *AutoReview) Reset(card Card, allCards []Card) {
+	a.input = newTypedInput()
+	a.submitted = false
+	a.diff = nil
+	a.ease = 0
+}
+
+func (a *AutoReview) Render(card Card, m model) string {
+	if !a.submitted {
+		return a.input.View() + "\n\n" + m.styles.prompt.Render("Press ENTER to check your answer")
+	}
+	content := m.renderDiff(a.diff)
+	content += "\n\n" + m.styles.prompt.Render(fmt.Sprintf("Auto-graded: %s. Press ENTER to continue.", card.EaseOptions[a.ease]))
+	return content
+}
+
+func (a *AutoReview) HandleKey(msg tea.KeyMsg, card Card) (int, bool, tea.Cmd) {
+	if !a.submitted {
+		if msg.String() == "enter" {
+			a.diff = computeDiffSegments(a.input.Value(), card.BackPlainText)
+			a.submitted = true
+			if normalizeAnswerText(a.input.Value()) == normalizeAnswerText(card.BackPlainText) {
+				a.ease = nearestAvailableEase(card, 3)
+			} else {
+				a.ease = nearestAvailableEase(card, 1)
+			}
+			return 0, false, nil
+		}
+		var cmd tea.Cmd
+		a.input, cmd = a.input.Update(msg)
+		return 0, false, cmd
+	}
+
+	if msg.String() == "enter" {
+		return a.ease, true, nil
+	}
+	return 0, false, nil
+}
+
+// MultipleChoice presents the real back field alongside distractor backs sampled from other
+// cards in the session, and derives an ease from correctness plus a confidence prompt.
+type MultipleChoice struct {
+	options      []string
+	correctIndex int
+	selected     int // -1 until an option has been picked
+	confidence   int // 0 until asked, then 1 (low) to 3 (high)
+}
+
+const multipleChoiceOptionCount = 4
+
+func (mc *MultipleChoice) Reset(card Card, allCards []Card) {
+	distractors := sampleDistractorBacks(card, allCards, multipleChoiceOptionCount-1)
+	mc.options = append(distractors, card.Back)
+	rand.Shuffle(len(mc.options), func(i, j int) {
+		mc.options[i], mc.options[j] = mc.options[j], mc.options[i]
+	})
+	mc.correctIndex = 0
+	for i, opt := range mc.options {
+		if opt == card.Back {
+			mc.correctIndex = i
+			break
+		}
+	}
+	mc.selected = -1
+	mc.confidence = 0
+}
+
+func (mc *MultipleChoice) Render(card Card, m model) string {
+	if mc.selected == -1 {
+		content := ""
+		for i, opt := range mc.options {
+			content += fmt.Sprintf("\n%s", m.styles.button.Render(fmt.Sprintf("%d: %s", i+1, opt)))
+		}
+		return content
+	}
+
+	if mc.confidence == 0 {
+		if mc.selected == mc.correctIndex {
+			return m.styles.prompt.Render("Correct! How confident were you? 1: Low 2: Medium 3: High")
+		}
+		return m.styles.back.Render(fmt.Sprintf("Incorrect. The answer was: %s", card.Back)) +
+			"\n\n" + m.styles.prompt.Render("Press ENTER to continue.")
+	}
+
+	return m.styles.prompt.Render(fmt.Sprintf("Graded: %s", card.EaseOptions[mc.ease(card)]))
+}
+
+func (mc *MultipleChoice) HandleKey(msg tea.KeyMsg, card Card) (int, bool, tea.Cmd) {
+	if mc.selected == -1 {
+		choice, err := strconv.Atoi(msg.String())
+		if err != nil || choice < 1 || choice > len(mc.options) {
+			return 0, false, nil
+		}
+		mc.selected = choice - 1
+		if mc.selected != mc.correctIndex {
+			// Wrong answers skip the confidence prompt; there's nothing to be confident about.
+			return 0, false, nil
+		}
+		return 0, false, nil
+	}
+
+	if mc.selected != mc.correctIndex {
+		if msg.String() == "enter" {
+			return mc.ease(card), true, nil
+		}
+		return 0, false, nil
+	}
+
+	if mc.confidence == 0 {
+		switch msg.String() {
+		case "1", "2", "3":
+			mc.confidence, _ = strconv.Atoi(msg.String())
+		}
+		return 0, false, nil
+	}
+
+	if msg.String() == "enter" {
+		return mc.ease(card), true, nil
+	}
+	return 0, false, nil
+}
+
+// ease derives the grade from correctness and, when correct, the reported confidence.
+func (mc *MultipleChoice) ease(card Card) int {
+	if mc.selected != mc.correctIndex {
+		return nearestAvailableEase(card, 1) // Again
+	}
+	if mc.confidence == 3 {
+		return nearestAvailableEase(card, 4) // Easy
+	}
+	return nearestAvailableEase(card, 3) // Good
+}
+
+// sampleDistractorBacks picks up to n other cards' Back fields at random, excluding the
+// current card and skipping duplicates of its Back field.
+func sampleDistractorBacks(card Card, allCards []Card, n int) []string {
+	candidates := make([]string, 0, len(allCards))
+	for _, c := range allCards {
+		if c.ID == card.ID || c.Back == card.Back {
+			continue
+		}
+		candidates = append(candidates, c.Back)
+	}
+	rand.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+	if len(candidates) > n {
+		candidates = candidates[:n]
+	}
+	return candidates
+}
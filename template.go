@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// modelTemplatesParams for the modelTemplates action
+type modelTemplatesParams struct {
+	ModelName string `json:"modelName"`
+}
+
+// findModelsByNameParams for the findModelsByName action
+type findModelsByNameParams struct {
+	ModelNames []string `json:"modelNames"`
+}
+
+// templateCache avoids a modelTemplates round-trip for every card of the same note type.
+// modelName -> template name -> {"Front": qfmt, "Back": afmt}
+var templateCache = map[string]map[string]map[string]string{}
+
+// templateOrderCache caches, per model, the template name at each ord - the real per-template
+// ord reported by findModelsByName's "tmpls" array, not a guess.
+var templateOrderCache = map[string][]string{}
+
+// modelTemplateOrder returns a model's template names indexed by their real ord, from
+// findModelsByName's "tmpls" array. modelTemplates alone doesn't expose ord (it's keyed by
+// template name), and a template's name sorts in no particular relationship to its ord -
+// custom-named templates ("Recognition"/"Production") or a 10th+ default-named template
+// ("Card 10" sorting before "Card 2") would both break a guess based on sorted names.
+func modelTemplateOrder(modelName string) ([]string, error) {
+	result, err := postAnkiConnect("findModelsByName", 6, findModelsByNameParams{ModelNames: []string{modelName}})
+	if err != nil {
+		return nil, err
+	}
+	models, ok := result.([]interface{})
+	if !ok || len(models) == 0 {
+		return nil, fmt.Errorf("unexpected findModelsByName result for model %q", modelName)
+	}
+	modelObj, ok := models[0].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected findModelsByName model format for %q", modelName)
+	}
+	rawTmpls, ok := modelObj["tmpls"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("model %q has no tmpls in findModelsByName result", modelName)
+	}
+
+	names := make([]string, len(rawTmpls))
+	for _, raw := range rawTmpls {
+		tmpl, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		ordF, ok := tmpl["ord"].(float64)
+		if !ok {
+			continue
+		}
+		ord := int(ordF)
+		if ord < 0 || ord >= len(names) {
+			return nil, fmt.Errorf("template ord %d out of range for model %q (%d templates)", ord, modelName, len(names))
+		}
+		name, _ := tmpl["name"].(string)
+		names[ord] = name
+	}
+	return names, nil
+}
+
+// fetchCardTemplate returns the qfmt/afmt pair for a card template, selected by its
+// zero-based `ord` within the note type.
+func fetchCardTemplate(modelName string, ord int) (qfmt, afmt string, err error) {
+	templates, ok := templateCache[modelName]
+	if !ok {
+		result, reqErr := postAnkiConnect("modelTemplates", 6, modelTemplatesParams{ModelName: modelName})
+		if reqErr != nil {
+			return "", "", reqErr
+		}
+		raw, ok := result.(map[string]interface{})
+		if !ok {
+			return "", "", fmt.Errorf("unexpected modelTemplates result format: %T", result)
+		}
+		templates = make(map[string]map[string]string, len(raw))
+		for name, sides := range raw {
+			sideMap, ok := sides.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			front, _ := sideMap["Front"].(string)
+			back, _ := sideMap["Back"].(string)
+			templates[name] = map[string]string{"Front": front, "Back": back}
+		}
+		templateCache[modelName] = templates
+	}
+
+	names, ok := templateOrderCache[modelName]
+	if !ok {
+		names, err = modelTemplateOrder(modelName)
+		if err != nil {
+			return "", "", err
+		}
+		templateOrderCache[modelName] = names
+	}
+	if len(names) == 1 {
+		// A single-template note type (such as Cloze) uses its one template for every card;
+		// a cloze card's ord is its cloze number minus one, not a template index.
+		ord = 0
+	}
+	if ord < 0 || ord >= len(names) {
+		return "", "", fmt.Errorf("card ord %d out of range for model %q (%d templates)", ord, modelName, len(names))
+	}
+
+	t, ok := templates[names[ord]]
+	if !ok {
+		return "", "", fmt.Errorf("template %q (ord %d) not found in modelTemplates for model %q", names[ord], ord, modelName)
+	}
+	return t["Front"], t["Back"], nil
+}
+
+var (
+	conditionalPattern = regexp.MustCompile(`(?s)\{\{(#|\^)([^}]+)\}\}(.*?)\{\{/\2\}\}`)
+	fieldTagPattern    = regexp.MustCompile(`\{\{([^}#/^]+)\}\}`)
+	clozeSpanPattern   = regexp.MustCompile(`\{\{c(\d+)::(.*?)(?:::(.*?))?\}\}`)
+)
+
+// renderCardSide renders a qfmt/afmt template against a card's fields: resolving
+// {{FrontSide}}, {{#Field}}/{{^Field}} conditional sections and {{FieldName}}/
+// {{cloze:FieldName}} substitutions, then revealing or hiding the cloze deletion numbered
+// targetCloze depending on which side is being rendered.
+func renderCardSide(tmpl string, fields map[string]fieldValue, frontSide string, targetCloze int, side string) string {
+	tmpl = strings.ReplaceAll(tmpl, "{{FrontSide}}", frontSide)
+	tmpl = resolveConditionals(tmpl, fields)
+
+	rendered := fieldTagPattern.ReplaceAllStringFunc(tmpl, func(tag string) string {
+		name := fieldTagPattern.FindStringSubmatch(tag)[1]
+		name = strings.TrimPrefix(name, "cloze:")
+		return fields[name].Value
+	})
+
+	return clozeSpanPattern.ReplaceAllStringFunc(rendered, func(m string) string {
+		groups := clozeSpanPattern.FindStringSubmatch(m)
+		num, _ := strconv.Atoi(groups[1])
+		text, hint := groups[2], groups[3]
+		if num != targetCloze {
+			return text // other cloze numbers are always shown in full, on both sides
+		}
+		if side == "answer" {
+			return text
+		}
+		if hint != "" {
+			return "[" + hint + "]"
+		}
+		return "[...]"
+	})
+}
+
+// resolveConditionals expands (non-nested) {{#Field}}...{{/Field}} and {{^Field}}...{{/Field}}
+// sections based on whether the named field is empty.
+func resolveConditionals(tmpl string, fields map[string]fieldValue) string {
+	return conditionalPattern.ReplaceAllStringFunc(tmpl, func(m string) string {
+		groups := conditionalPattern.FindStringSubmatch(m)
+		kind, name, body := groups[1], groups[2], groups[3]
+		has := strings.TrimSpace(fields[name].Value) != ""
+		if (kind == "#" && has) || (kind == "^" && !has) {
+			return body
+		}
+		return ""
+	})
+}
+
+// renderCard renders a card's question and answer sides from its note type's template,
+// falling back to the raw Front/Back fields if the template can't be resolved (e.g. the
+// modelTemplates call fails). It returns the terminal-ready front and back, plus a
+// markup-free version of the Back field (or, for a note type without one, of the rendered
+// answer side) for typed-answer comparison.
+func renderCard(ci cardInfoResult, st styles) (front, back, backPlain string) {
+	qfmt, afmt, err := fetchCardTemplate(ci.ModelName, ci.Ord)
+	if err != nil {
+		front = ci.Fields["Front"].Value
+		back = ci.Fields["Back"].Value
+		return htmlToTerminal(front, st), htmlToTerminal(back, st), normalizeAnswerText(back)
+	}
+
+	targetCloze := ci.Ord + 1
+	rawFront := renderCardSide(qfmt, ci.Fields, "", targetCloze, "question")
+	rawBack := renderCardSide(afmt, ci.Fields, rawFront, targetCloze, "answer")
+	backPlain = normalizeAnswerText(rawBack)
+	if field, ok := ci.Fields["Back"]; ok {
+		// The rendered answer side usually repeats the question via {{FrontSide}}, so a
+		// typed answer is compared against the Back field itself when the note type has one.
+		backPlain = normalizeAnswerText(field.Value)
+	}
+	return htmlToTerminal(rawFront, st), htmlToTerminal(rawBack, st), backPlain
+}